@@ -0,0 +1,359 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	cryptFile = "crypt.json"
+
+	// cryptMagic is the 4-byte magic+version header written at the start
+	// of every encrypted object.
+	cryptMagic = "BKP1"
+
+	cryptNoncePrefixSize = 16
+	cryptChunkSize       = 64 * 1024
+	cryptNonceSize       = cryptNoncePrefixSize + 8 // 16 random bytes + 8-byte LE chunk counter
+
+	scryptN      = 16384
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 96 // 32 bytes data key + 32 bytes name-cipher key + 32 bytes name-MAC key
+)
+
+type (
+	cryptCfg struct {
+		Enabled       bool   `json:"enabled"`
+		PassphraseEnv string `json:"passphraseEnv"`
+		Passphrase    string `json:"passphrase"`
+	}
+
+	// cryptParams is persisted to .backup/crypt.json (and uploaded like
+	// metadata.json) so the salt and KDF knobs used to derive the keys
+	// survive across machines without ever storing the passphrase itself.
+	cryptParams struct {
+		Salt string `json:"salt"`
+		N    int    `json:"n"`
+		R    int    `json:"r"`
+		P    int    `json:"p"`
+	}
+
+	cryptState struct {
+		params  cryptParams
+		dataKey []byte
+		// nameCipherKey and nameMACKey are deliberately distinct: the MAC
+		// that derives a segment's synthetic IV must not share a key with
+		// the cipher that IV feeds, or the synthetic-IV construction's
+		// independence assumption breaks.
+		nameCipherKey []byte
+		nameMACKey    []byte
+		block         cipher.Block
+		gcm           cipher.AEAD
+	}
+)
+
+func loadCryptState(bnp string, cfg cryptCfg) *cryptState {
+	passphrase := cfg.Passphrase
+	if cfg.PassphraseEnv != "" {
+		passphrase = os.Getenv(cfg.PassphraseEnv)
+	}
+	if passphrase == "" {
+		checkError(errors.New("crypt: enabled but no passphrase or passphraseEnv configured"))
+	}
+
+	cpn := path.Join(bnp, cryptFile)
+	params, err := readCryptParams(cpn)
+	if errors.Is(err, os.ErrNotExist) {
+		salt := make([]byte, 16)
+		_, err = rand.Read(salt)
+		checkError(err)
+		params = cryptParams{
+			Salt: base32.StdEncoding.EncodeToString(salt),
+			N:    scryptN,
+			R:    scryptR,
+			P:    scryptP,
+		}
+		b, err := json.Marshal(params)
+		checkError(err)
+		err = os.WriteFile(cpn, b, 0600)
+		checkError(err)
+	} else {
+		checkError(err)
+	}
+
+	salt, err := base32.StdEncoding.DecodeString(params.Salt)
+	checkError(err)
+	key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, scryptKeyLen)
+	checkError(err)
+
+	dataKey := key[:32]
+	nameCipherKey := key[32:64]
+	nameMACKey := key[64:96]
+	block, err := aes.NewCipher(dataKey)
+	checkError(err)
+	gcm, err := cipher.NewGCMWithNonceSize(block, cryptNonceSize)
+	checkError(err)
+
+	return &cryptState{
+		params:        params,
+		dataKey:       dataKey,
+		nameCipherKey: nameCipherKey,
+		nameMACKey:    nameMACKey,
+		block:         block,
+		gcm:           gcm,
+	}
+}
+
+func readCryptParams(cpn string) (cryptParams, error) {
+	var params cryptParams
+	b, err := os.ReadFile(cpn)
+	if err != nil {
+		return params, err
+	}
+	err = json.Unmarshal(b, &params)
+	return params, err
+}
+
+// encryptReader wraps src so that reading it yields the encrypted stream:
+// a 4-byte magic+version header, a 16-byte random per-file nonce prefix,
+// then a sequence of length-prefixed, GCM-sealed 64KiB chunks. Each chunk's
+// nonce is the prefix concatenated with an 8-byte little-endian counter, so
+// reordered or substituted chunks fail to authenticate. The final chunk is
+// always an explicit zero-length, flagged chunk so decryption can detect
+// truncation instead of silently accepting a short stream.
+type encryptReader struct {
+	cs        *cryptState
+	src       io.Reader
+	noncePfx  [cryptNoncePrefixSize]byte
+	counter   uint64
+	buf       []byte
+	sentHdr   bool
+	sentFinal bool
+}
+
+func newEncryptReader(cs *cryptState, src io.Reader) (io.Reader, error) {
+	er := &encryptReader{cs: cs, src: src}
+	if _, err := rand.Read(er.noncePfx[:]); err != nil {
+		return nil, fmt.Errorf("crypt: generating nonce prefix: %w", err)
+	}
+	return er, nil
+}
+
+func (er *encryptReader) Read(p []byte) (int, error) {
+	for len(er.buf) == 0 {
+		if !er.sentHdr {
+			er.buf = append([]byte(cryptMagic), er.noncePfx[:]...)
+			er.sentHdr = true
+			break
+		}
+		if er.sentFinal {
+			return 0, io.EOF
+		}
+		plain := make([]byte, cryptChunkSize)
+		n, err := io.ReadFull(er.src, plain)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, fmt.Errorf("crypt: reading plaintext: %w", err)
+		}
+		final := n == 0
+		sealed, sErr := er.seal(plain[:n], final)
+		if sErr != nil {
+			return 0, sErr
+		}
+		er.buf = sealed
+		er.sentFinal = final
+	}
+	n := copy(p, er.buf)
+	er.buf = er.buf[n:]
+	return n, nil
+}
+
+func (er *encryptReader) seal(plain []byte, final bool) ([]byte, error) {
+	nonce := cryptNonce(er.noncePfx, er.counter)
+	er.counter++
+	aad := []byte{0}
+	if final {
+		aad = []byte{1}
+	}
+	sealed := er.cs.gcm.Seal(nil, nonce[:], plain, aad)
+	out := make([]byte, 4+len(sealed))
+	binary.BigEndian.PutUint32(out, uint32(len(sealed)))
+	copy(out[4:], sealed)
+	return out, nil
+}
+
+// decryptReader is the inverse of encryptReader; it rejects a ciphertext
+// that ends before its final-flagged chunk has been seen.
+type decryptReader struct {
+	cs        *cryptState
+	src       io.Reader
+	noncePfx  [cryptNoncePrefixSize]byte
+	counter   uint64
+	buf       []byte
+	readHdr   bool
+	sawFinal  bool
+}
+
+func newDecryptReader(cs *cryptState, src io.Reader) (io.Reader, error) {
+	return &decryptReader{cs: cs, src: src}, nil
+}
+
+func (dr *decryptReader) Read(p []byte) (int, error) {
+	for len(dr.buf) == 0 {
+		if !dr.readHdr {
+			hdr := make([]byte, 4+cryptNoncePrefixSize)
+			if _, err := io.ReadFull(dr.src, hdr); err != nil {
+				return 0, fmt.Errorf("crypt: reading header: %w", err)
+			}
+			if string(hdr[:4]) != cryptMagic {
+				return 0, fmt.Errorf("crypt: unrecognized object header %q", hdr[:4])
+			}
+			copy(dr.noncePfx[:], hdr[4:])
+			dr.readHdr = true
+			continue
+		}
+		if dr.sawFinal {
+			return 0, io.EOF
+		}
+		lenBuf := make([]byte, 4)
+		if _, err := io.ReadFull(dr.src, lenBuf); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return 0, errors.New("crypt: ciphertext truncated before final chunk")
+			}
+			return 0, fmt.Errorf("crypt: reading chunk length: %w", err)
+		}
+		sealed := make([]byte, binary.BigEndian.Uint32(lenBuf))
+		if _, err := io.ReadFull(dr.src, sealed); err != nil {
+			return 0, fmt.Errorf("crypt: reading chunk: %w", err)
+		}
+		plain, final, err := dr.open(sealed)
+		if err != nil {
+			return 0, err
+		}
+		dr.buf = plain
+		dr.sawFinal = final
+	}
+	n := copy(p, dr.buf)
+	dr.buf = dr.buf[n:]
+	return n, nil
+}
+
+func (dr *decryptReader) open(sealed []byte) ([]byte, bool, error) {
+	nonce := cryptNonce(dr.noncePfx, dr.counter)
+	for _, aad := range [][]byte{{0}, {1}} {
+		if plain, err := dr.cs.gcm.Open(nil, nonce[:], sealed, aad); err == nil {
+			dr.counter++
+			return plain, aad[0] == 1, nil
+		}
+	}
+	return nil, false, fmt.Errorf("crypt: chunk %d failed authentication", dr.counter)
+}
+
+func cryptNonce(prefix [cryptNoncePrefixSize]byte, counter uint64) [cryptNonceSize]byte {
+	var nonce [cryptNonceSize]byte
+	copy(nonce[:cryptNoncePrefixSize], prefix[:])
+	binary.LittleEndian.PutUint64(nonce[cryptNoncePrefixSize:], counter)
+	return nonce
+}
+
+// obfuscateKey replaces every "/"-separated segment of key with a
+// deterministic, reversible encryption of that segment so directory
+// structure (and therefore pruning) keeps working without exposing names.
+// Each segment is sealed with AES-CTR under a synthetic IV — HMAC-SHA256
+// of the plaintext segment, keyed with nameMACKey, independent of the
+// nameCipherKey the CTR stream itself uses — so the same segment always
+// obfuscates to the same name and the IV travels with the ciphertext, no
+// separate state needed to reverse it. deobfuscateSegment re-derives that
+// IV from the decrypted plaintext and rejects a mismatch, so a tampered
+// segment name is caught rather than silently decrypted to garbage.
+func (cs *cryptState) obfuscateKey(key string) (string, error) {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		if s == "" {
+			continue
+		}
+		obf, err := cs.obfuscateSegment(s)
+		if err != nil {
+			return "", err
+		}
+		segments[i] = obf
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+func (cs *cryptState) obfuscateSegment(segment string) (string, error) {
+	mac := hmac.New(sha256.New, cs.nameMACKey)
+	mac.Write([]byte(segment))
+	iv := mac.Sum(nil)[:aes.BlockSize]
+
+	block, err := aes.NewCipher(cs.nameCipherKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext := make([]byte, len(segment))
+	cipher.NewCTR(block, iv).XORKeyStream(ciphertext, []byte(segment))
+
+	return base32.HexEncoding.WithPadding(base32.NoPadding).EncodeToString(append(iv, ciphertext...)), nil
+}
+
+// deobfuscateKey is the inverse of obfuscateKey.
+func (cs *cryptState) deobfuscateKey(key string) (string, error) {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		if s == "" {
+			continue
+		}
+		plain, err := cs.deobfuscateSegment(s)
+		if err != nil {
+			return "", err
+		}
+		segments[i] = plain
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+func (cs *cryptState) deobfuscateSegment(encoded string) (string, error) {
+	raw, err := base32.HexEncoding.WithPadding(base32.NoPadding).DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < aes.BlockSize {
+		return "", errors.New("crypt: obfuscated segment too short")
+	}
+	iv, ciphertext := raw[:aes.BlockSize], raw[aes.BlockSize:]
+
+	block, err := aes.NewCipher(cs.nameCipherKey)
+	if err != nil {
+		return "", err
+	}
+	plain := make([]byte, len(ciphertext))
+	cipher.NewCTR(block, iv).XORKeyStream(plain, ciphertext)
+
+	// The synthetic IV only provides tamper evidence if we actually check
+	// it: re-derive it from the candidate plaintext and require it match
+	// the IV stored alongside the ciphertext. A bit flipped in transit
+	// changes the corresponding plaintext byte (CTR is malleable) but
+	// can't be made to also reproduce the right IV without the MAC key.
+	mac := hmac.New(sha256.New, cs.nameMACKey)
+	mac.Write(plain)
+	wantIV := mac.Sum(nil)[:aes.BlockSize]
+	if !hmac.Equal(iv, wantIV) {
+		return "", errors.New("crypt: obfuscated segment failed authentication")
+	}
+
+	return string(plain), nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base32"
+	"io"
+	"testing"
+)
+
+func testCryptState(t *testing.T) *cryptState {
+	t.Helper()
+	bnp := t.TempDir()
+	return loadCryptState(bnp, cryptCfg{Passphrase: "correct horse battery staple"})
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	cs := testCryptState(t)
+	plain := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog"), 5000) // spans multiple 64KiB chunks
+
+	enc, err := newEncryptReader(cs, bytes.NewReader(plain))
+	if err != nil {
+		t.Fatalf("newEncryptReader: %v", err)
+	}
+	ciphertext, err := io.ReadAll(enc)
+	if err != nil {
+		t.Fatalf("reading ciphertext: %v", err)
+	}
+	if bytes.Contains(ciphertext, plain[:64]) {
+		t.Fatal("ciphertext contains a recognizable run of plaintext")
+	}
+
+	dec, err := newDecryptReader(cs, bytes.NewReader(ciphertext))
+	if err != nil {
+		t.Fatalf("newDecryptReader: %v", err)
+	}
+	got, err := io.ReadAll(dec)
+	if err != nil {
+		t.Fatalf("reading plaintext: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Fatal("round-tripped plaintext does not match original")
+	}
+}
+
+func TestDecryptRejectsTruncation(t *testing.T) {
+	cs := testCryptState(t)
+	plain := bytes.Repeat([]byte("x"), cryptChunkSize+1) // forces a second chunk
+
+	enc, err := newEncryptReader(cs, bytes.NewReader(plain))
+	if err != nil {
+		t.Fatalf("newEncryptReader: %v", err)
+	}
+	ciphertext, err := io.ReadAll(enc)
+	if err != nil {
+		t.Fatalf("reading ciphertext: %v", err)
+	}
+
+	truncated := ciphertext[:len(ciphertext)-1]
+	dec, err := newDecryptReader(cs, bytes.NewReader(truncated))
+	if err != nil {
+		t.Fatalf("newDecryptReader: %v", err)
+	}
+	if _, err := io.ReadAll(dec); err == nil {
+		t.Fatal("expected truncated ciphertext to fail decryption")
+	}
+}
+
+func TestObfuscateKeyRoundTrip(t *testing.T) {
+	cs := testCryptState(t)
+	key := "myhost/home/user/Documents/secret plan.txt"
+
+	obf, err := cs.obfuscateKey(key)
+	if err != nil {
+		t.Fatalf("obfuscateKey: %v", err)
+	}
+	if obf == key {
+		t.Fatal("obfuscated key should not equal the plaintext key")
+	}
+
+	plain, err := cs.deobfuscateKey(obf)
+	if err != nil {
+		t.Fatalf("deobfuscateKey: %v", err)
+	}
+	if plain != key {
+		t.Fatalf("got %q, want %q", plain, key)
+	}
+}
+
+func TestDeobfuscateSegmentRejectsTampering(t *testing.T) {
+	cs := testCryptState(t)
+	obf, err := cs.obfuscateSegment("secret plan.txt")
+	if err != nil {
+		t.Fatalf("obfuscateSegment: %v", err)
+	}
+
+	raw, err := base32.HexEncoding.WithPadding(base32.NoPadding).DecodeString(obf)
+	if err != nil {
+		t.Fatalf("decoding obfuscated segment: %v", err)
+	}
+	raw[len(raw)-1] ^= 0xff // flip a ciphertext byte, as if tampered in transit
+	tampered := base32.HexEncoding.WithPadding(base32.NoPadding).EncodeToString(raw)
+
+	if _, err := cs.deobfuscateSegment(tampered); err == nil {
+		t.Fatal("expected tampered segment to fail authentication")
+	}
+}
+
+func TestObfuscateKeyDeterministic(t *testing.T) {
+	cs := testCryptState(t)
+	a, err := cs.obfuscateKey("host/file.txt")
+	if err != nil {
+		t.Fatalf("obfuscateKey: %v", err)
+	}
+	b, err := cs.obfuscateKey("host/file.txt")
+	if err != nil {
+		t.Fatalf("obfuscateKey: %v", err)
+	}
+	if a != b {
+		t.Fatal("obfuscating the same key twice should be deterministic")
+	}
+}
@@ -0,0 +1,59 @@
+// Package b2 implements backend.Backend against Backblaze B2. B2 exposes
+// an S3-compatible API, so rather than hand-rolling the native b2_* REST
+// calls we reuse the s3 backend against B2's S3-compatible endpoint — same
+// code path as AWS, fewer moving parts to maintain.
+package b2
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/emersonkopp/backup/internal/backend"
+	s3backend "github.com/emersonkopp/backup/internal/backend/s3"
+)
+
+type Config struct {
+	Bucket             string
+	Endpoint           string // e.g. https://s3.us-west-004.backblazeb2.com
+	Region             string // e.g. us-west-004
+	KeyID              string
+	ApplicationKey     string
+	PartSize           int64
+	Concurrency        int
+	MultipartThreshold int64
+	MaxRetries         int
+	InitialBackoff     time.Duration
+}
+
+type Backend struct {
+	*s3backend.Backend
+}
+
+func New(ctx context.Context, cfg Config) (*Backend, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRegion(cfg.Region),
+		awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(cfg.KeyID, cfg.ApplicationKey, "")),
+	)
+	if err != nil {
+		return nil, err
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(cfg.Endpoint)
+		o.UsePathStyle = true
+	})
+	return &Backend{Backend: s3backend.New(client, s3backend.Config{
+		Bucket:             cfg.Bucket,
+		PartSize:           cfg.PartSize,
+		Concurrency:        cfg.Concurrency,
+		MultipartThreshold: cfg.MultipartThreshold,
+		MaxRetries:         cfg.MaxRetries,
+		InitialBackoff:     cfg.InitialBackoff,
+	})}, nil
+}
+
+var _ backend.Backend = (*Backend)(nil)
@@ -0,0 +1,54 @@
+// Package backend defines the storage abstraction runner uploads, deletes,
+// and lists through, so the tool isn't wired directly to one provider's SDK.
+package backend
+
+import (
+	"context"
+	"io"
+	"iter"
+)
+
+// ObjectInfo describes one object already present at a backend, as
+// returned by List.
+type ObjectInfo struct {
+	Key  string
+	Size int64
+	// ETag is the backend's content fingerprint, when it has one. S3
+	// populates it (verbatim, including the "-<parts>" multipart
+	// suffix); backends without an equivalent concept leave it empty.
+	ETag string
+	// ChecksumSHA256 is populated only when the backend can supply one
+	// without an extra round trip per object; callers must treat an
+	// empty string as "unknown", not "mismatch".
+	ChecksumSHA256 string
+}
+
+// Backend is the storage operations runner needs. Implementations live
+// under backend/<name> so new destinations (S3, a local/NAS path, B2, GCS,
+// ...) plug in without touching runner's traversal or encryption logic.
+type Backend interface {
+	// Put uploads the body open returns as key. size is the best known
+	// length in bytes, or -1 if unknown (e.g. once it's wrapped for
+	// encryption). A backend that retries calls open again for each
+	// attempt rather than assuming the first reader it got is seekable —
+	// callers whose body is a TeeReader (progress) or a cipher stream
+	// (encryption) can't rewind the composed reader, but can cheaply
+	// reopen and re-wrap it from the underlying file.
+	Put(ctx context.Context, key string, open func() (io.Reader, error), size int64, metadata map[string]string) error
+	Delete(ctx context.Context, key string) error
+	// List yields every object whose key starts with prefix. Iteration
+	// stops at the first error.
+	List(ctx context.Context, prefix string) iter.Seq2[ObjectInfo, error]
+	// Get returns the object's content alongside whatever metadata was
+	// attached on Put (e.g. "mtime"), for restore to apply.
+	Get(ctx context.Context, key string) (io.ReadCloser, map[string]string, error)
+}
+
+// ChecksumBackend is implemented by backends that can supply an object's
+// content checksum only on request, as opposed to for free during List
+// (S3's ListObjectsV2 doesn't return one; GetObjectAttributes does, at the
+// cost of an extra round trip per candidate). syncModeChecksum calls this
+// only for files a size match didn't already rule out.
+type ChecksumBackend interface {
+	Checksum(ctx context.Context, key string) (string, error)
+}
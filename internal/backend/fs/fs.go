@@ -0,0 +1,111 @@
+// Package fs implements backend.Backend against a local (or
+// NAS/rclone-mounted) directory tree, mainly useful for staging and for
+// exercising runner without touching AWS.
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/emersonkopp/backup/internal/backend"
+)
+
+type Backend struct {
+	baseDir string
+}
+
+func New(baseDir string) *Backend {
+	return &Backend{baseDir: baseDir}
+}
+
+func (b *Backend) path(key string) string {
+	return filepath.Join(b.baseDir, filepath.FromSlash(key))
+}
+
+func (b *Backend) Put(_ context.Context, key string, open func() (io.Reader, error), _ int64, metadata map[string]string) error {
+	body, err := open()
+	if err != nil {
+		return fmt.Errorf("fs: opening body for %s: %w", key, err)
+	}
+	p := b.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0750); err != nil {
+		return fmt.Errorf("fs: creating %s: %w", filepath.Dir(p), err)
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return fmt.Errorf("fs: creating %s: %w", p, err)
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return fmt.Errorf("fs: writing %s: %w", p, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("fs: writing %s: %w", p, err)
+	}
+	// fs has no header store of its own, so mtime metadata is round-tripped
+	// through the real filesystem timestamp instead.
+	if mt, ok := metadata["mtime"]; ok {
+		if t, err := time.Parse(time.RFC3339Nano, mt); err == nil {
+			os.Chtimes(p, t, t)
+		}
+	}
+	return nil
+}
+
+func (b *Backend) Delete(_ context.Context, key string) error {
+	if err := os.Remove(b.path(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("fs: removing %s: %w", key, err)
+	}
+	return nil
+}
+
+func (b *Backend) Get(_ context.Context, key string) (io.ReadCloser, map[string]string, error) {
+	p := b.path(key)
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fs: opening %s: %w", key, err)
+	}
+	metadata := map[string]string{}
+	if info, err := f.Stat(); err == nil {
+		metadata["mtime"] = info.ModTime().UTC().Format(time.RFC3339Nano)
+	}
+	return f, metadata, nil
+}
+
+func (b *Backend) List(_ context.Context, prefix string) iter.Seq2[backend.ObjectInfo, error] {
+	return func(yield func(backend.ObjectInfo, error) bool) {
+		root := b.path(prefix)
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && p == root {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(b.baseDir, p)
+			if err != nil {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if !yield(backend.ObjectInfo{Key: filepath.ToSlash(rel), Size: info.Size()}, nil) {
+				return filepath.SkipAll
+			}
+			return nil
+		})
+		if err != nil {
+			yield(backend.ObjectInfo{}, fmt.Errorf("fs: walking %s: %w", root, err))
+		}
+	}
+}
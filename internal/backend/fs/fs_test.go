@@ -0,0 +1,61 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+func TestPutGetRoundTrip(t *testing.T) {
+	b := New(t.TempDir())
+	ctx := context.Background()
+	want := []byte("hello, backup")
+
+	open := func() (io.Reader, error) { return bytes.NewReader(want), nil }
+	if err := b.Put(ctx, "host/dir/file.txt", open, int64(len(want)), nil); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	rc, _, err := b.Get(ctx, "host/dir/file.txt")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestListAndDelete(t *testing.T) {
+	b := New(t.TempDir())
+	ctx := context.Background()
+	open := func() (io.Reader, error) { return bytes.NewReader([]byte("x")), nil }
+	for _, key := range []string{"host/a.txt", "host/sub/b.txt", "other/c.txt"} {
+		if err := b.Put(ctx, key, open, 1, nil); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+
+	var keys []string
+	for obj, err := range b.List(ctx, "host/") {
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		keys = append(keys, obj.Key)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("got %d keys under host/, want 2: %v", len(keys), keys)
+	}
+
+	if err := b.Delete(ctx, "host/a.txt"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, _, err := b.Get(ctx, "host/a.txt"); err == nil {
+		t.Fatal("expected Get to fail after Delete")
+	}
+}
@@ -0,0 +1,192 @@
+// Package s3 implements backend.Backend against AWS S3 (or any
+// S3-compatible endpoint, by constructing the *s3.Client with a custom
+// endpoint resolver before calling New).
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithy "github.com/aws/smithy-go"
+
+	"github.com/emersonkopp/backup/internal/backend"
+)
+
+const (
+	defaultMaxRetries     = 3
+	defaultInitialBackoff = 500 * time.Millisecond
+)
+
+type Config struct {
+	Bucket             string
+	PartSize           int64
+	Concurrency        int
+	MultipartThreshold int64
+
+	// MaxRetries and InitialBackoff bound the exponential-backoff retry
+	// Put and Delete apply to transient failures. Zero means the defaults
+	// above.
+	MaxRetries     int
+	InitialBackoff time.Duration
+}
+
+type Backend struct {
+	client   *s3.Client
+	uploader *manager.Uploader
+	cfg      Config
+}
+
+var _ backend.ChecksumBackend = (*Backend)(nil)
+
+func New(client *s3.Client, cfg Config) *Backend {
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultInitialBackoff
+	}
+	uploader := manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize = cfg.PartSize
+		u.Concurrency = cfg.Concurrency
+	})
+	return &Backend{client: client, uploader: uploader, cfg: cfg}
+}
+
+func (b *Backend) Put(ctx context.Context, key string, open func() (io.Reader, error), size int64, metadata map[string]string) error {
+	return b.retry(ctx, func() error {
+		body, err := open()
+		if err != nil {
+			return err
+		}
+		input := &s3.PutObjectInput{
+			Bucket:   aws.String(b.cfg.Bucket),
+			Key:      aws.String(key),
+			Body:     body,
+			Metadata: metadata,
+		}
+		if size >= 0 {
+			input.ContentLength = aws.Int64(size)
+		}
+		// The direct PutObject path has the SDK hash the body and then
+		// seek it back to the start, so it only works when body is
+		// seekable (the raw local file, with no progress tee or
+		// encryption wrapped around it). Anything we can't size up front,
+		// that crosses the configured threshold, or that isn't seekable
+		// goes through the multipart uploader instead, which streams the
+		// body into part buffers without ever needing to rewind it.
+		_, seekable := body.(io.Seeker)
+		if size < 0 || size >= b.cfg.MultipartThreshold || !seekable {
+			_, err := b.uploader.Upload(ctx, input)
+			return err
+		}
+		_, err = b.client.PutObject(ctx, input)
+		return err
+	})
+}
+
+func (b *Backend) Delete(ctx context.Context, key string) error {
+	return b.retry(ctx, func() error {
+		_, err := b.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(b.cfg.Bucket),
+			Key:    aws.String(key),
+		})
+		return err
+	})
+}
+
+// retry runs op up to cfg.MaxRetries additional times with doubling
+// backoff while the failure looks transient. A *types.NoSuchKey is never
+// retried (the object genuinely isn't there); a *smithy.OperationError is
+// assumed transient (timeouts, throttling, connection resets) and worth
+// another attempt. Callers that need a fresh reader each attempt (Put)
+// get one by calling back into op rather than this function trying to
+// rewind anything itself.
+func (b *Backend) retry(ctx context.Context, op func() error) error {
+	backoff := b.cfg.InitialBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = op()
+		if err == nil || attempt == b.cfg.MaxRetries || !retryable(err) {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func retryable(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return false
+	}
+	var opErr *smithy.OperationError
+	return errors.As(err, &opErr)
+}
+
+func (b *Backend) Get(ctx context.Context, key string) (io.ReadCloser, map[string]string, error) {
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.cfg.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return out.Body, out.Metadata, nil
+}
+
+// Checksum fetches an object's SHA-256 via GetObjectAttributes, for
+// syncModeChecksum candidates whose checksum didn't come back free with
+// the listing.
+func (b *Backend) Checksum(ctx context.Context, key string) (string, error) {
+	out, err := b.client.GetObjectAttributes(ctx, &s3.GetObjectAttributesInput{
+		Bucket:           aws.String(b.cfg.Bucket),
+		Key:              aws.String(key),
+		ObjectAttributes: []types.ObjectAttributes{types.ObjectAttributesChecksum},
+	})
+	if err != nil {
+		return "", err
+	}
+	if out.Checksum == nil {
+		return "", nil
+	}
+	return aws.ToString(out.Checksum.ChecksumSHA256), nil
+}
+
+func (b *Backend) List(ctx context.Context, prefix string) iter.Seq2[backend.ObjectInfo, error] {
+	return func(yield func(backend.ObjectInfo, error) bool) {
+		paginator := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{
+			Bucket: aws.String(b.cfg.Bucket),
+			Prefix: aws.String(prefix),
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				yield(backend.ObjectInfo{}, fmt.Errorf("s3: listing %s: %w", prefix, err))
+				return
+			}
+			for _, obj := range page.Contents {
+				info := backend.ObjectInfo{
+					Key:  aws.ToString(obj.Key),
+					Size: aws.ToInt64(obj.Size),
+					ETag: strings.Trim(aws.ToString(obj.ETag), `"`),
+				}
+				if !yield(info, nil) {
+					return
+				}
+			}
+		}
+	}
+}
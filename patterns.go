@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+const backupIgnoreFile = ".backupignore"
+
+// gitignorePattern is one line of a gitignore-style pattern list: a
+// doublestar glob, optionally negated with a leading "!", optionally
+// directory-only (trailing "/"), and either rooted at the configured root
+// (leading "/", or a "/" anywhere but the end) or matchable at any depth.
+// Patterns loaded from a nested .backupignore are matched against the path
+// relative to the configured root too, not relative to the directory that
+// holds the file.
+type gitignorePattern struct {
+	raw     string
+	negate  bool
+	dirOnly bool
+	rooted  bool
+	pattern string
+}
+
+func parsePattern(raw string) gitignorePattern {
+	p := gitignorePattern{raw: raw}
+	s := raw
+	if strings.HasPrefix(s, "!") {
+		p.negate = true
+		s = s[1:]
+	}
+	if strings.HasSuffix(s, "/") {
+		p.dirOnly = true
+		s = strings.TrimSuffix(s, "/")
+	}
+	if strings.HasPrefix(s, "/") {
+		p.rooted = true
+		s = strings.TrimPrefix(s, "/")
+	} else if strings.Contains(s, "/") {
+		p.rooted = true
+	}
+	p.pattern = s
+	return p
+}
+
+// compilePatterns parses an ordered list of raw gitignore-style lines,
+// skipping blank lines and "#" comments.
+func compilePatterns(lines []string) []gitignorePattern {
+	var patterns []gitignorePattern
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		patterns = append(patterns, parsePattern(l))
+	}
+	return patterns
+}
+
+// loadBackupIgnore reads a .backupignore file from dir, if present, scoped
+// to that directory's subtree only.
+func loadBackupIgnore(dir string) ([]gitignorePattern, error) {
+	b, err := os.ReadFile(path.Join(dir, backupIgnoreFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return compilePatterns(strings.Split(string(b), "\n")), nil
+}
+
+func (p gitignorePattern) matches(relPath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if p.rooted {
+		ok, _ := doublestar.Match(p.pattern, relPath)
+		return ok
+	}
+	if ok, _ := doublestar.Match(p.pattern, path.Base(relPath)); ok {
+		return true
+	}
+	ok, _ := doublestar.Match("**/"+p.pattern, relPath)
+	return ok
+}
+
+// matchPatterns applies patterns in order, last match wins, exactly like
+// gitignore: unmatched paths are not excluded, and "!" re-includes a path an
+// earlier pattern would otherwise exclude.
+func matchPatterns(patterns []gitignorePattern, relPath string, isDir bool) bool {
+	excluded := false
+	for _, p := range patterns {
+		if p.matches(relPath, isDir) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+// relPath returns filePath relative to root, the configured path this walk
+// started from, which is what patterns are evaluated against.
+func relPath(root, filePath string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(filePath, root), "/")
+}
+
+// excluded decides whether filePath should be skipped under cfg. Paths
+// opted into legacyRegex keep matching the old anchored include/exclude
+// regex lists; everything else is evaluated against cfg.patterns.
+func excluded(cfg pathCfg, root, filePath, name string, isDir bool) bool {
+	if cfg.LegacyRegex {
+		if isDir {
+			if len(cfg.includeFolders) > 0 && !match(name, cfg.includeFolders) {
+				return true
+			}
+			return match(name, cfg.excludeFolders)
+		}
+		if len(cfg.includeFiles) > 0 && !match(name, cfg.includeFiles) {
+			return true
+		}
+		return match(name, cfg.excludeFiles)
+	}
+	return matchPatterns(cfg.patterns, relPath(root, filePath), isDir)
+}
+
+// withBackupIgnore returns cfg extended with any .backupignore found in
+// dir, scoped to that directory's subtree only — sibling directories never
+// see it.
+func withBackupIgnore(cfg pathCfg, dir string) pathCfg {
+	extra, err := loadBackupIgnore(dir)
+	checkError(err)
+	if len(extra) == 0 {
+		return cfg
+	}
+	cfg.patterns = append(append([]gitignorePattern{}, cfg.patterns...), extra...)
+	return cfg
+}
@@ -0,0 +1,77 @@
+package main
+
+import "testing"
+
+func TestMatchPatternsLastMatchWins(t *testing.T) {
+	patterns := compilePatterns([]string{
+		"*.log",
+		"!important.log",
+		"build/",
+	})
+
+	cases := []struct {
+		relPath string
+		isDir   bool
+		want    bool
+	}{
+		{"debug.log", false, true},
+		{"important.log", false, false},
+		{"sub/debug.log", false, true},
+		{"build", true, true},
+		{"build", false, false}, // dir-only pattern doesn't match a file
+		{"main.go", false, false},
+	}
+	for _, c := range cases {
+		if got := matchPatterns(patterns, c.relPath, c.isDir); got != c.want {
+			t.Errorf("matchPatterns(%q, isDir=%v) = %v, want %v", c.relPath, c.isDir, got, c.want)
+		}
+	}
+}
+
+func TestMatchPatternsRootedVsAnywhere(t *testing.T) {
+	patterns := compilePatterns([]string{
+		"/only-at-root.txt",
+		"anywhere.txt",
+	})
+
+	if matchPatterns(patterns, "sub/only-at-root.txt", false) {
+		t.Error("rooted pattern should not match a nested path")
+	}
+	if !matchPatterns(patterns, "only-at-root.txt", false) {
+		t.Error("rooted pattern should match at its declared root")
+	}
+	if !matchPatterns(patterns, "sub/anywhere.txt", false) {
+		t.Error("unrooted pattern should match at any depth")
+	}
+}
+
+func TestExcludedLegacyRegex(t *testing.T) {
+	c := &configuration{Paths: map[string]pathCfg{"p": {
+		LegacyRegex:  true,
+		ExcludeFiles: []string{"secret\\.txt"},
+	}}}
+	compile(c)
+	cfg := c.Paths["p"]
+
+	if !excluded(cfg, "/root", "/root/secret.txt", "secret.txt", false) {
+		t.Error("excludeFiles regex should exclude a matching file")
+	}
+	if excluded(cfg, "/root", "/root/keep.txt", "keep.txt", false) {
+		t.Error("excludeFiles regex should not exclude a non-matching file")
+	}
+}
+
+func TestCompileAutoDetectsUnmigratedConfig(t *testing.T) {
+	c := &configuration{Paths: map[string]pathCfg{"p": {
+		ExcludeFiles: []string{"secret\\.txt"},
+	}}}
+	compile(c)
+	cfg := c.Paths["p"]
+
+	if !cfg.LegacyRegex {
+		t.Fatal("a config with legacy filters and no patterns should auto-detect legacyRegex")
+	}
+	if !excluded(cfg, "/root", "/root/secret.txt", "secret.txt", false) {
+		t.Error("legacy excludeFiles should still apply after auto-detection")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// progressBar tracks bytes written for a single file upload and feeds an
+// aggregate totalBar shared across all in-flight uploads.
+type progressBar struct {
+	label string
+	size  int64
+	done  int64
+	total *totalBar
+}
+
+// totalBar aggregates bytes uploaded across every file of the run so far
+// and reports a running bytes/sec figure on a ticker.
+type totalBar struct {
+	done  int64
+	start time.Time
+	stop  chan struct{}
+}
+
+func newTotalBar() *totalBar {
+	tb := &totalBar{
+		start: time.Now(),
+		stop:  make(chan struct{}),
+	}
+	go tb.report()
+	return tb
+}
+
+func (tb *totalBar) report() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			tb.print()
+		case <-tb.stop:
+			return
+		}
+	}
+}
+
+func (tb *totalBar) print() {
+	done := atomic.LoadInt64(&tb.done)
+	elapsed := time.Since(tb.start).Seconds()
+	if elapsed <= 0 {
+		elapsed = 1
+	}
+	rate := uint64(float64(done) / elapsed)
+	fmt.Printf("Total uploaded: %s (%s/s)\n", humanize.Bytes(uint64(done)), humanize.Bytes(rate))
+}
+
+func (tb *totalBar) close() {
+	close(tb.stop)
+	tb.print()
+}
+
+func newProgressBar(label string, size int64, total *totalBar) *progressBar {
+	return &progressBar{
+		label: label,
+		size:  size,
+		total: total,
+	}
+}
+
+// Write implements io.Writer so the bar can be driven via io.TeeReader.
+func (pb *progressBar) Write(p []byte) (int, error) {
+	n := len(p)
+	done := atomic.AddInt64(&pb.done, int64(n))
+	atomic.AddInt64(&pb.total.done, int64(n))
+	fmt.Printf("\r%s: %s / %s", pb.label, humanize.Bytes(uint64(done)), humanize.Bytes(uint64(pb.size)))
+	if done >= pb.size {
+		fmt.Println()
+	}
+	return n, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/emersonkopp/backup/internal/backend"
+)
+
+// restore is the inverse of run: instead of walking the local filesystem
+// and uploading, it walks the backend's object listing for a host and
+// recreates what it finds under flags.restoreDest. Like plan/run, it's a
+// dry run unless -run is also passed. A single object's failure is
+// recorded rather than aborting the rest of the listing; restore collects
+// them all into its final report.
+func (r *runner) restore() error {
+	host := r.host
+	if r.flags.restoreHost != "" {
+		host = r.flags.restoreHost
+	}
+	prefix := host + "/"
+	if r.flags.restorePrefix != "" {
+		prefix = path.Join(prefix, r.flags.restorePrefix)
+	}
+
+	var n int
+	var errs []error
+	for obj, err := range r.backend.List(r.ctx, prefix) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("listing %s: %w", prefix, err))
+			continue
+		}
+		ok, err := r.restoreObject(host, obj)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("restoring %s: %w", obj.Key, err))
+			continue
+		}
+		if ok {
+			n++
+		}
+	}
+	fmt.Println("Restored", n, "objects")
+	return errors.Join(errs...)
+}
+
+// restoreObject restores a single listed object and reports whether it
+// actually wrote (or would write) a file, as opposed to skipping it.
+func (r *runner) restoreObject(host string, obj backend.ObjectInfo) (bool, error) {
+	localKey := strings.TrimPrefix(obj.Key, host)
+	// metadata.json and crypt.json are the tool's own bookkeeping, not
+	// something a restore should recreate as a regular file. They are
+	// stored under plaintext keys even when encryption is enabled, so this
+	// check comes before deobfuscation.
+	if base := path.Base(localKey); base == metadataFile || base == cryptFile {
+		return false, nil
+	}
+	if r.crypt != nil {
+		var err error
+		localKey, err = r.crypt.deobfuscateKey(localKey)
+		if err != nil {
+			return false, fmt.Errorf("deobfuscating %s: %w", obj.Key, err)
+		}
+	}
+
+	dest := filepath.Join(r.flags.restoreDest, filepath.FromSlash(localKey))
+
+	body, meta, err := r.backend.Get(r.ctx, obj.Key)
+	if err != nil {
+		return false, fmt.Errorf("getting %s: %w", obj.Key, err)
+	}
+	defer body.Close()
+
+	var mtime time.Time
+	if mt, ok := meta["mtime"]; ok {
+		mtime, err = time.Parse(time.RFC3339Nano, mt)
+		if err != nil {
+			return false, fmt.Errorf("parsing mtime %q: %w", mt, err)
+		}
+	}
+
+	if existing, err := os.Stat(dest); err == nil {
+		if existing.Size() == obj.Size && (mtime.IsZero() || existing.ModTime().Equal(mtime)) {
+			fmt.Println("Up to date:", dest)
+			return false, nil
+		}
+	}
+
+	if !r.flags.run {
+		fmt.Println("Would restore", dest, "...")
+		return true, nil
+	}
+	fmt.Println("Restoring", dest, "...")
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+		return false, fmt.Errorf("creating %s: %w", filepath.Dir(dest), err)
+	}
+
+	var src io.Reader = body
+	if r.crypt != nil {
+		dec, err := newDecryptReader(r.crypt, body)
+		if err != nil {
+			return false, fmt.Errorf("decrypting %s: %w", obj.Key, err)
+		}
+		src = dec
+	}
+
+	f, err := os.Create(dest)
+	if err != nil {
+		return false, fmt.Errorf("creating %s: %w", dest, err)
+	}
+	_, cpErr := io.Copy(f, src)
+	closeErr := f.Close()
+	if cpErr != nil {
+		return false, fmt.Errorf("writing %s: %w", dest, cpErr)
+	}
+	if closeErr != nil {
+		return false, fmt.Errorf("writing %s: %w", dest, closeErr)
+	}
+
+	if !mtime.IsZero() {
+		if err := os.Chtimes(dest, mtime, mtime); err != nil {
+			return false, fmt.Errorf("setting mtime on %s: %w", dest, err)
+		}
+	}
+	return true, nil
+}
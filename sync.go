@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/emersonkopp/backup/internal/backend"
+)
+
+type syncMode string
+
+const (
+	// syncModeMtime is the original behavior: trust the local
+	// metadata.json cache of mtimes.
+	syncModeMtime syncMode = "mtime"
+	// syncModeETag compares local content against the bucket's ETag,
+	// recomputing a composite multipart ETag when needed.
+	syncModeETag syncMode = "etag"
+	// syncModeChecksum compares a local SHA-256 against the object's
+	// stored checksum instead of its ETag. syncModeETag does not fall back
+	// to it: an ETag it cannot recompute is settled by the size match alone.
+	syncModeChecksum syncMode = "checksum"
+)
+
+// remoteObject is what we learn about an already-uploaded file from the
+// backend's object listing, keyed by its local (pre-obfuscation) path.
+type remoteObject struct {
+	Size           int64
+	ETag           string
+	ChecksumSHA256 string
+}
+
+// loadRemoteState lists every object under host and returns it keyed by
+// local path, undoing the host prefix and, when cs is non-nil, the
+// per-segment name obfuscation so it lines up with r.processed and
+// r.metadata. Only used for syncModeETag/syncModeChecksum: metadata.json
+// remains an optional local cache otherwise.
+func loadRemoteState(ctx context.Context, be backend.Backend, host string, cs *cryptState) (map[string]remoteObject, error) {
+	state := map[string]remoteObject{}
+	prefix := host + "/"
+	for obj, err := range be.List(ctx, prefix) {
+		if err != nil {
+			return nil, fmt.Errorf("sync: listing %s: %w", prefix, err)
+		}
+		local := strings.TrimPrefix(obj.Key, host)
+		// metadata.json and crypt.json keep plaintext keys (see objectKey).
+		if base := path.Base(local); cs != nil && base != metadataFile && base != cryptFile {
+			var dErr error
+			local, dErr = cs.deobfuscateKey(local)
+			if dErr != nil {
+				return nil, fmt.Errorf("sync: deobfuscating %s: %w", obj.Key, dErr)
+			}
+		}
+		state[local] = remoteObject{
+			Size:           obj.Size,
+			ETag:           obj.ETag,
+			ChecksumSHA256: obj.ChecksumSHA256,
+		}
+	}
+	return state, nil
+}
+
+func syncUsesRemoteState(mode syncMode) bool {
+	return mode == syncModeETag || mode == syncModeChecksum
+}
+
+// needsUpload decides whether filePath must be (re-)uploaded. f is
+// rewound to the start before returning regardless of outcome, since the
+// caller goes on to read it for the upload itself.
+func (r *runner) needsUpload(filePath string, f *os.File, s os.FileInfo) (bool, error) {
+	// Every encrypted object is sealed with a fresh random nonce, so its
+	// ciphertext (and therefore its ETag/checksum) differs from the last
+	// upload even when the plaintext hasn't changed. Comparing local
+	// plaintext against remote ciphertext fingerprints can never match,
+	// which would silently degrade etag/checksum sync into "upload
+	// everything, every run" — so encrypted backups deliberately fall
+	// back to the mtime cache instead of pretending delta sync still
+	// works.
+	if !syncUsesRemoteState(r.config.SyncMode) || r.crypt != nil {
+		r.metaMu.Lock()
+		mt, ok := r.metadata[filePath]
+		r.metaMu.Unlock()
+		return !(ok && mt.Equal(s.ModTime())), nil
+	}
+
+	obj, ok := r.remote[filePath]
+	if !ok || obj.Size != s.Size() {
+		return true, nil
+	}
+
+	return r.contentDiffers(filePath, f, obj)
+}
+
+func (r *runner) contentDiffers(filePath string, f *os.File, obj remoteObject) (differs bool, err error) {
+	defer func() {
+		if _, serr := f.Seek(0, io.SeekStart); serr != nil && err == nil {
+			err = fmt.Errorf("rewinding %s: %w", filePath, serr)
+		}
+	}()
+
+	if r.config.SyncMode == syncModeChecksum {
+		sum256 := obj.ChecksumSHA256
+		if sum256 == "" {
+			// The listing didn't come with a checksum; ask the backend for
+			// this one candidate directly, if it's able to.
+			if cb, ok := r.backend.(backend.ChecksumBackend); ok {
+				key, kErr := r.objectKey(filePath)
+				if kErr != nil {
+					return true, kErr
+				}
+				fetched, err := cb.Checksum(r.ctx, key)
+				if err != nil {
+					return true, fmt.Errorf("sync: fetching checksum for %s: %w", filePath, err)
+				}
+				sum256 = fetched
+			}
+		}
+		if sum256 == "" {
+			// Still unknown (backend can't supply one at all); fall back
+			// to the size match we already have rather than treating
+			// "unknown" as "mismatch".
+			return false, nil
+		}
+		sum, err := localSHA256(f)
+		if err != nil {
+			return true, err
+		}
+		return sum != sum256, nil
+	}
+
+	if !strings.Contains(obj.ETag, "-") {
+		sum, err := localMD5(f)
+		if err != nil {
+			return true, err
+		}
+		return sum != obj.ETag, nil
+	}
+
+	parts := strings.SplitN(obj.ETag, "-", 2)
+	wantCount, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return true, fmt.Errorf("sync: malformed multipart etag %q: %w", obj.ETag, err)
+	}
+	composite, count, err := compositeMD5ETag(f, r.config.Upload.PartSize)
+	if err != nil {
+		return true, err
+	}
+	if count != wantCount {
+		// The part size on record no longer matches what produced this
+		// object's ETag; we can't recompute it, so trust the size match.
+		return false, nil
+	}
+	return composite != obj.ETag, nil
+}
+
+func localMD5(f *os.File) (string, error) {
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func localSHA256(f *os.File) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// compositeMD5ETag reproduces S3's multipart ETag: the MD5 of the
+// concatenated per-part MD5 digests, suffixed with "-<part count>".
+func compositeMD5ETag(f *os.File, partSize int64) (string, int, error) {
+	if partSize <= 0 {
+		partSize = defaultPartSize
+	}
+	var digests []byte
+	buf := make([]byte, partSize)
+	count := 0
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			sum := md5.Sum(buf[:n])
+			digests = append(digests, sum[:]...)
+			count++
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return "", 0, err
+		}
+	}
+	final := md5.Sum(digests)
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(final[:]), count), count, nil
+}
+
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/emersonkopp/backup/internal/backend/fs"
+)
+
+func TestCompositeMD5ETag(t *testing.T) {
+	const partSize = 8
+	data := []byte("abcdefghijklmnopqrstuvwxy") // 25 bytes -> parts of 8,8,8,1
+
+	f, err := os.CreateTemp(t.TempDir(), "composite-etag")
+	if err != nil {
+		t.Fatalf("CreateTemp: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+
+	etag, count, err := compositeMD5ETag(f, partSize)
+	if err != nil {
+		t.Fatalf("compositeMD5ETag: %v", err)
+	}
+	if count != 4 {
+		t.Fatalf("got %d parts, want 4", count)
+	}
+
+	var digests []byte
+	for i := 0; i < len(data); i += partSize {
+		end := min(i+partSize, len(data))
+		sum := md5.Sum(data[i:end])
+		digests = append(digests, sum[:]...)
+	}
+	final := md5.Sum(digests)
+	want := fmt.Sprintf("%s-%d", hex.EncodeToString(final[:]), 4)
+
+	if etag != want {
+		t.Fatalf("got %q, want %q", etag, want)
+	}
+}
+
+func TestCompositeMD5ETagSinglePart(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "composite-etag-single")
+	if err != nil {
+		t.Fatalf("CreateTemp: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte("short")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+
+	_, count, err := compositeMD5ETag(f, defaultPartSize)
+	if err != nil {
+		t.Fatalf("compositeMD5ETag: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("got %d parts, want 1", count)
+	}
+}
+
+// TestEncryptedObjectKeyIsListable proves the host prefix objectKey
+// produces for an encrypted backup still lines up with the literal
+// "<host>/" prefix loadRemoteState (and restore) list by — regression
+// coverage for objectKey having obfuscated the host segment along with
+// the path, which made every object invisible to both of those.
+func TestEncryptedObjectKeyIsListable(t *testing.T) {
+	cs := testCryptState(t)
+	r := &runner{
+		host:         "myhost",
+		crypt:        cs,
+		metadataFile: "/home/user/.backup/metadata.json",
+		cryptFile:    "/home/user/.backup/crypt.json",
+	}
+	filePath := "/home/user/Documents/secret.txt"
+
+	key, err := r.objectKey(filePath)
+	if err != nil {
+		t.Fatalf("objectKey: %v", err)
+	}
+	if !strings.HasPrefix(key, r.host+"/") {
+		t.Fatalf("key %q should start with the plaintext host prefix %q", key, r.host+"/")
+	}
+
+	be := fs.New(t.TempDir())
+	ctx := context.Background()
+	open := func() (io.Reader, error) { return bytes.NewReader([]byte("shh")), nil }
+	if err := be.Put(ctx, key, open, 3, nil); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	remote, err := loadRemoteState(ctx, be, r.host, cs)
+	if err != nil {
+		t.Fatalf("loadRemoteState: %v", err)
+	}
+	if _, ok := remote[filePath]; !ok {
+		t.Fatalf("loadRemoteState(%q) = %v, missing %q", r.host, remote, filePath)
+	}
+}